@@ -4,10 +4,15 @@ import (
   "fmt"
   "log"
   "bytes"
+  "hash"
   "math"
-  "crypto/sha256"
-  "crypto/sha512"
   "crypto/rand"
+  "encoding/hex"
+  "io"
+  "runtime"
+  "strconv"
+  "sync"
+  "blockmatrix/gf127"
 )
 // https://csrc.nist.gov/publications/detail/white-paper/2018/05/31/data-structure-for-integrity-protection-with-erasure-capability/draft
 
@@ -35,6 +40,38 @@ type BlockMatrix struct {
   ColumnHashes []string        // Column hashes
   HashOfColumns string         // Hash of all column hashes
   HashOfMatrix string          // Hash of diagonal elements
+
+  // The following fields are only populated when HashAlgorithm is TZHash.
+  // cellMatrices[i][j] mirrors BlockHashes[i][j] but keeps the GF(2^127)
+  // matrix form of the cell's hash, which is what makes row/column hashes
+  // homomorphically combinable. rowPrefix[i][k]/rowSuffix[i][k] cache the
+  // product of row i's cell matrices strictly before/from column k, so a
+  // single cell update only has to recompute the prefix and suffix entries
+  // on the side of the matrix that actually changed, rather than rehash
+  // the whole row. colPrefix/colSuffix do the same thing for columns.
+  cellMatrices [][]gf127.Matrix
+  rowPrefix [][]gf127.Matrix
+  rowSuffix [][]gf127.Matrix
+  colPrefix [][]gf127.Matrix
+  colSuffix [][]gf127.Matrix
+
+  // storage optionally backs BlockData with a Storage implementation so a
+  // matrix larger than RAM can still be built and later reopened. It is
+  // nil for a plain Create(), which keeps everything in BlockData as before.
+  // storageErr is guarded by storageErrMu since InsertBlocks can drive
+  // setCellHash/persistCellHash from multiple effectiveParallelism() workers
+  // at once once CreateWithOptions and a Storage backend are combined
+  storage Storage
+  storageErr error
+  storageErrMu sync.Mutex
+
+  // hasherFunc and parallelism are only set by CreateWithOptions. hasherFunc
+  // overrides HashAlgorithm's SHA-family hash.Hash constructor when non-nil;
+  // parallelism is the worker count InsertBlocks and row/column hash
+  // recomputation use, 1 (i.e. sequential, the zero value's behavior) for
+  // every other constructor
+  hasherFunc func() hash.Hash
+  parallelism int
 }
 
 // Developers can use this variable to trace program execution
@@ -47,6 +84,24 @@ const (
   Sha384 = "SHA384"
   Sha512 = "SHA512"
 
+  // TZHash is a Tillich-Zemor style homomorphic hash over GF(2^127). Each
+  // block's hash is a 2x2 matrix, and row/column hashes are the matrix
+  // product of their cells rather than the hash of a concatenated string,
+  // which allows them to be updated in O(N) matrix multiplies instead of
+  // O(N) hash-of-hash rebuilds on every insertion or deletion. See the
+  // gf127 subpackage for the field and generator matrices. HashOfRows,
+  // HashOfColumns and HashOfMatrix remain fixed-size SHA256 digests of the
+  // serialized matrix products, so external verifiers still see ordinary
+  // hex digests.
+  //
+  // WARNING: the gf127 generator matrices this scheme is built on (A0/A1,
+  // the natural Tillich-Zemor companion matrices for x and x + 1) have a
+  // published efficient collision-finding attack, so TZHash's per-block
+  // hash provides homomorphic combination but not collision resistance.
+  // Pick Sha256/Sha384/Sha512 instead when collision resistance matters
+  // more than incremental row/column updates
+  TZHash = "TZHASH"
+
   // Diagonal cells are filled with random data, this is the length of random blocks
   RandomBlockLength = 64
 )
@@ -59,150 +114,485 @@ func Create( Dimension int, HashAlgorithm string ) *BlockMatrix {
 
   if TraceEnabled { log.Printf("Create(%d, %s) called\n", Dimension, HashAlgorithm) }
 
-  bm := new(BlockMatrix)
-  bm.Dimension = Dimension
-  bm.HashAlgorithm = HashAlgorithm
-  bm.BlockData = make([][]bytes.Buffer, Dimension)
-  for i := 0; i < Dimension; i++ { bm.BlockData[i] = make([]bytes.Buffer, Dimension) }
+  bm := newBlockMatrix(Dimension, HashAlgorithm, nil)
+  bm.fillDiagonalWithRandomData()
+  bm.updateHashOfMatrix()
 
-  bm.BlockHashes = make([][]string, Dimension)
-  for i := 0; i < Dimension; i++ { bm.BlockHashes[i] = make([]string, Dimension) }
+  if TraceEnabled { log.Printf("Create() returning %v\n", &bm) }
 
-  bm.RowHashes = make([]string, Dimension)
-  bm.ColumnHashes = make([]string, Dimension)
+  return bm
+}
+
+// CreateWithOptions is Create, but lets the caller override the worker pool
+// size InsertBlocks and row/column hash recomputation use, and/or swap in a
+// different hash.Hash constructor for every SHA-family digest this matrix
+// computes (TZHash's own per-block homomorphic hash is unaffected). A zero
+// value Options yields the same hashes as Create, but unlike Create it is
+// not sequential: Parallelism <= 0 defaults to runtime.NumCPU(), and a nil
+// Hasher leaves HashAlgorithm in charge
+
+func CreateWithOptions( Dimension int, HashAlgorithm string, opts Options ) *BlockMatrix {
+
+  if TraceEnabled { log.Printf("CreateWithOptions(%d, %s) called\n", Dimension, HashAlgorithm) }
+
+  bm := newBlockMatrix(Dimension, HashAlgorithm, nil)
+  bm.hasherFunc = opts.Hasher
+
+  bm.parallelism = opts.Parallelism
+  if bm.parallelism <= 0 { bm.parallelism = runtime.NumCPU() }
 
   bm.fillDiagonalWithRandomData()
   bm.updateHashOfMatrix()
 
-  if TraceEnabled { log.Printf("Create() returning %v\n", &bm) }
+  if TraceEnabled { log.Printf("CreateWithOptions() returning %v\n", &bm) }
 
   return bm
 }
 
-// an internal function to compute the hash of a stream of bytes
+// effectiveParallelism is the worker count InsertBlocks and row/column hash
+// recomputation should use. It is 1 (sequential, preserving Create's exact
+// original ordering) unless CreateWithOptions set bm.parallelism
 
-func (bm *BlockMatrix) hashOfBytes( Data bytes.Buffer ) string {
+func (bm *BlockMatrix) effectiveParallelism() int {
 
-  var HashStr string
+  if bm.parallelism <= 0 { return 1 }
 
-  HashStr = ""
+  return bm.parallelism
+}
 
-  if bm.HashAlgorithm == Sha256 {
+// CreateWithStorage is Create, but every cell written from here on is also
+// persisted to storage as it's filled, so the matrix can later be rebuilt
+// with Open instead of being held in BlockData forever
 
-    HashStr = fmt.Sprintf("%x", sha256.Sum256(Data.Bytes()))
-  } else if bm.HashAlgorithm == Sha384 {
+func CreateWithStorage( Dimension int, HashAlgorithm string, storage Storage ) ( *BlockMatrix, error ) {
 
-    HashStr = fmt.Sprintf("%x", sha512.Sum384(Data.Bytes()))
-  } else if bm.HashAlgorithm == Sha512 {
+  if TraceEnabled { log.Printf("CreateWithStorage(%d, %s) called\n", Dimension, HashAlgorithm) }
 
-    HashStr = fmt.Sprintf("%x", sha512.Sum512(Data.Bytes()))
-  }
+  bm := newBlockMatrix(Dimension, HashAlgorithm, storage)
+  bm.fillDiagonalWithRandomData()
+  bm.updateHashOfMatrix()
+  bm.persistMeta()
+
+  if bm.storageErr != nil { return nil, bm.storageErr }
 
-  return HashStr
+  return bm, nil
 }
 
-// an internal function to compute the hash of a given string
+// Open reconstructs a BlockMatrix from a Storage backend previously
+// populated by CreateWithStorage. BlockData is left empty: cell contents
+// are fetched from storage on demand by GetBlockData/GetBlockDataReader,
+// while BlockHashes are restored immediately from the persisted meta and
+// the row/column hashes and HashOfMatrix are recomputed from them, so the
+// matrix is verifiable right away. The persisted row/column and aggregate
+// hash meta entries are not read
+
+func Open( storage Storage ) ( *BlockMatrix, error ) {
+
+  if TraceEnabled { log.Printf("Open() called\n") }
 
-func (bm *BlockMatrix) hashOfString( DataStr string ) string {
+  dimBytes, err := storage.GetMeta(metaKeyDimension)
+  if err != nil { return nil, err }
 
-  var HashStr string
+  Dimension, err := strconv.Atoi(string(dimBytes))
+  if err != nil { return nil, err }
 
-  HashStr = ""
+  algoBytes, err := storage.GetMeta(metaKeyHashAlgorithm)
+  if err != nil { return nil, err }
 
-  if bm.HashAlgorithm == Sha256 {
+  bm := newBlockMatrix(Dimension, string(algoBytes), storage)
 
-    HashStr = fmt.Sprintf("%x", sha256.Sum256([]byte(DataStr)))
-  } else if bm.HashAlgorithm == Sha384 {
+  for i := 0; i < Dimension; i++ {
 
-    HashStr = fmt.Sprintf("%x", sha512.Sum384([]byte(DataStr)))
-  } else if bm.HashAlgorithm == Sha512 {
+    for j := 0; j < Dimension; j++ {
 
-    HashStr = fmt.Sprintf("%x", sha512.Sum512([]byte(DataStr)))
+      h, err := storage.GetMeta(blockHashMetaKey(i, j))
+      if err != nil { continue } // cell was never written; cellMatrices[i][j] is already identity from allocateMatrixCaches
+
+      bm.BlockHashes[i][j] = string(h)
+
+      if bm.HashAlgorithm == TZHash {
+
+        if len(h) == 0 {
+          bm.cellMatrices[i][j] = gf127.IdentityMatrix() // GDPR deleted, contributes nothing
+        } else {
+          bm.cellMatrices[i][j] = gf127.MatrixFromBytes(mustHexDecode(string(h)))
+        }
+      }
+    }
   }
 
-  return HashStr
+  bm.rebuildDerivedState()
+
+  if TraceEnabled { log.Printf("Open() returning %v\n", &bm) }
+
+  return bm, nil
 }
 
-// an internal function to compute/update the hash of all rows's hashes
-// Each row's hash is concatenated and the resulting string is hashed
+// FromState reconstructs a BlockMatrix from previously exported state, e.g.
+// state round-tripped through blockmatrixgob.Unmarshal. BlockData/BlockHashes
+// must already reflect every cell including the diagonal; row/column
+// hashes and HashOfMatrix are then rebuilt (and, for TZHash, so are the
+// cell matrix caches) rather than trusted as-is, so the returned matrix is
+// always internally consistent with the cell data it was given
 
-func (bm *BlockMatrix) updateHashOfRows() {
+func FromState( Dimension int, HashAlgorithm string, BlockData [][]bytes.Buffer, BlockHashes [][]string ) *BlockMatrix {
 
-  var Hashes string
+  bm := newBlockMatrix(Dimension, HashAlgorithm, nil)
+  bm.BlockData = BlockData
+  bm.BlockHashes = BlockHashes
 
-  Hashes = ""
-  for i := 0; i < bm.Dimension; i++ {
+  if bm.HashAlgorithm == TZHash {
+
+    for i := 0; i < Dimension; i++ {
 
-    Hashes += bm.RowHashes[i]
+      for j := 0; j < Dimension; j++ {
+
+        if len(BlockHashes[i][j]) == 0 {
+          bm.cellMatrices[i][j] = gf127.IdentityMatrix()
+        } else {
+          bm.cellMatrices[i][j] = gf127.MatrixFromBytes(mustHexDecode(BlockHashes[i][j]))
+        }
+      }
+    }
   }
 
-  bm.HashOfRows = bm.hashOfString(Hashes)
+  bm.rebuildDerivedState()
+
+  return bm
 }
 
-// an internal function to compute/update the hash of all column's hashes
-// Each column's hash is concatenated and the resulting string is hashed
+// rebuildDerivedState recomputes row hashes, column hashes and
+// HashOfMatrix (and, for TZHash, the prefix/suffix matrix caches) from
+// bm.BlockHashes/bm.cellMatrices. Used after restoring a matrix from
+// storage or from a serialized snapshot, where only the per-cell state is
+// known to be trustworthy
 
-func (bm *BlockMatrix) updateHashOfColumns() {
+func (bm *BlockMatrix) rebuildDerivedState() {
 
-  var Hashes string
+  if bm.HashAlgorithm == TZHash {
 
-  Hashes = ""
-  for j := 0; j < bm.Dimension; j++ {
+    for i := 0; i < bm.Dimension; i++ { bm.recomputeRowMatrix(i) }
+    for j := 0; j < bm.Dimension; j++ { bm.recomputeColumnMatrix(j) }
+    bm.updateHashOfRows()
+    bm.updateHashOfColumns()
+
+  } else {
 
-    Hashes += bm.ColumnHashes[j]
+    bm.updateRowHashes(0, bm.Dimension)
+    bm.updateColumnHashes(0, bm.Dimension)
   }
 
-  bm.HashOfColumns = bm.hashOfString(Hashes)
+  bm.updateHashOfMatrix()
+}
+
+// newBlockMatrix allocates a BlockMatrix's arrays; shared by Create,
+// CreateWithStorage and Open
+
+func newBlockMatrix( Dimension int, HashAlgorithm string, storage Storage ) *BlockMatrix {
+
+  bm := new(BlockMatrix)
+  bm.Dimension = Dimension
+  bm.HashAlgorithm = HashAlgorithm
+  bm.storage = storage
+
+  bm.BlockData = make([][]bytes.Buffer, Dimension)
+  for i := 0; i < Dimension; i++ { bm.BlockData[i] = make([]bytes.Buffer, Dimension) }
+
+  bm.BlockHashes = make([][]string, Dimension)
+  for i := 0; i < Dimension; i++ { bm.BlockHashes[i] = make([]string, Dimension) }
+
+  bm.RowHashes = make([]string, Dimension)
+  bm.ColumnHashes = make([]string, Dimension)
+
+  if bm.HashAlgorithm == TZHash { bm.allocateMatrixCaches() }
+
+  return bm
+}
+
+// StorageErr returns the first error encountered while writing to this
+// matrix's Storage backend, if any. It is nil for a plain Create() matrix,
+// which has no backend to fail
+
+func (bm *BlockMatrix) StorageErr() error {
+
+  bm.storageErrMu.Lock()
+  defer bm.storageErrMu.Unlock()
+
+  return bm.storageErr
+}
+
+// recordStorageErr sets bm.storageErr to err if it isn't already set,
+// keeping only the first error seen. Safe to call concurrently
+
+func (bm *BlockMatrix) recordStorageErr( err error ) {
+
+  bm.storageErrMu.Lock()
+  defer bm.storageErrMu.Unlock()
+
+  if bm.storageErr == nil { bm.storageErr = err }
+}
+
+// an internal function to compute the hash of a stream of bytes. For the
+// SHA family this streams Data straight into bm.newHasher() instead of
+// buffering through fmt.Sprintf
+
+func (bm *BlockMatrix) hashOfBytes( Data bytes.Buffer ) string {
+
+  if bm.HashAlgorithm == TZHash {
+
+    return hex.EncodeToString(gf127.Hash(Data.Bytes()).Bytes())
+  }
+
+  hr := bm.newHasher()
+  hr.Write(Data.Bytes())
+
+  return hr.SumHex()
+}
+
+// an internal function to compute/update the hash of all rows's hashes
+// Each row's hash is streamed into one hasher instead of being concatenated
+// into a string first
+
+func (bm *BlockMatrix) updateHashOfRows() {
+
+  hr := bm.newHasher()
+  for i := 0; i < bm.Dimension; i++ { hr.WriteString(bm.RowHashes[i]) }
+
+  bm.HashOfRows = hr.SumHex()
+}
+
+// an internal function to compute/update the hash of all column's hashes
+// Each column's hash is streamed into one hasher instead of being
+// concatenated into a string first
+
+func (bm *BlockMatrix) updateHashOfColumns() {
+
+  hr := bm.newHasher()
+  for j := 0; j < bm.Dimension; j++ { hr.WriteString(bm.ColumnHashes[j]) }
+
+  bm.HashOfColumns = hr.SumHex()
 }
 
 // an internal function to compute/update row hashes
 // From and To specify the range to update
 // If you want to update row 3's hash, then use From = 3 and To = 4
 // Diagonal elements (i = j) are excluded in hash computation
+// Rows in the range are recomputed across bm.effectiveParallelism() workers
 
 func (bm *BlockMatrix) updateRowHashes( From int, To int ) {
 
   if TraceEnabled { log.Printf("updateRowHashes(%d, %d) called\n", From, To) }
 
-  for i := From; i < To; i++ {
+  parallelFor(From, To, bm.effectiveParallelism(), func( i int ) {
 
-    var Hashes string
+    if bm.HashAlgorithm == TZHash {
 
-    Hashes = ""
-    for j := 0; j < bm.Dimension; j++ {
-
-      if i != j { Hashes += bm.BlockHashes[i][j] }
+      bm.recomputeRowMatrix(i)
+      return
     }
 
-    bm.RowHashes[i] = bm.hashOfString(Hashes)
-  }
+    bm.RowHashes[i] = bm.computeRowHash(i)
+  })
 
   bm.updateHashOfRows()
 }
 
+// computeRowHash streams row i's non-diagonal block hashes into one hasher,
+// replacing the old build-a-string-then-hash-it approach
+
+func (bm *BlockMatrix) computeRowHash( i int ) string {
+
+  hr := bm.newHasher()
+  for j := 0; j < bm.Dimension; j++ {
+
+    if i != j { hr.WriteString(bm.BlockHashes[i][j]) }
+  }
+
+  return hr.SumHex()
+}
+
 // an internal function to compute/update column hashes
 // From and To specify the range to update
 // If you want to update column 3's hash, then use From = 3 and To = 4
 // Diagonal elements (i = j) are excluded in hash computation
+// Columns in the range are recomputed across bm.effectiveParallelism() workers
 
 func (bm *BlockMatrix) updateColumnHashes( From int, To int ) {
 
   if TraceEnabled { log.Printf("updateColumnHashes(%d, %d) called\n", From, To) }
 
-  for j := From; j < To; j++ {
+  parallelFor(From, To, bm.effectiveParallelism(), func( j int ) {
 
-    var Hashes string
+    if bm.HashAlgorithm == TZHash {
 
-    Hashes = ""
-    for i := 0; i < bm.Dimension; i++ {
+      bm.recomputeColumnMatrix(j)
+      return
+    }
+
+    bm.ColumnHashes[j] = bm.computeColHash(j)
+  })
 
-      if i != j { Hashes += bm.BlockHashes[i][j] }
+  bm.updateHashOfColumns()
+}
+
+// computeColHash is the column analog of computeRowHash
+
+func (bm *BlockMatrix) computeColHash( j int ) string {
+
+  hr := bm.newHasher()
+  for i := 0; i < bm.Dimension; i++ {
+
+    if i != j { hr.WriteString(bm.BlockHashes[i][j]) }
+  }
+
+  return hr.SumHex()
+}
+
+// allocateMatrixCaches allocates the per-cell matrix and prefix/suffix
+// product caches used by the TZHash homomorphic hash scheme
+
+func (bm *BlockMatrix) allocateMatrixCaches() {
+
+  bm.cellMatrices = make([][]gf127.Matrix, bm.Dimension)
+  bm.rowPrefix = make([][]gf127.Matrix, bm.Dimension)
+  bm.rowSuffix = make([][]gf127.Matrix, bm.Dimension)
+  bm.colPrefix = make([][]gf127.Matrix, bm.Dimension)
+  bm.colSuffix = make([][]gf127.Matrix, bm.Dimension)
+
+  for i := 0; i < bm.Dimension; i++ {
+
+    bm.cellMatrices[i] = make([]gf127.Matrix, bm.Dimension)
+    for j := 0; j < bm.Dimension; j++ { bm.cellMatrices[i][j] = gf127.IdentityMatrix() } // never-written cell contributes nothing to its row/column product, same as a GDPR-deleted one
+
+    bm.rowPrefix[i] = make([]gf127.Matrix, bm.Dimension + 1)
+    bm.rowSuffix[i] = make([]gf127.Matrix, bm.Dimension + 1)
+    bm.colPrefix[i] = make([]gf127.Matrix, bm.Dimension + 1)
+    bm.colSuffix[i] = make([]gf127.Matrix, bm.Dimension + 1)
+  }
+}
+
+// recomputeRowMatrix rebuilds row i's prefix/suffix matrix products from
+// scratch and derives RowHashes[i] from the resulting full row product.
+// Used when every cell in the row may have changed, e.g. InsertBlocks
+
+func (bm *BlockMatrix) recomputeRowMatrix( i int ) {
+
+  prefix := bm.rowPrefix[i]
+  suffix := bm.rowSuffix[i]
+
+  prefix[0] = gf127.IdentityMatrix()
+  for k := 0; k < bm.Dimension; k++ {
+
+    if k == i {
+      prefix[k + 1] = prefix[k]
+    } else {
+      prefix[k + 1] = prefix[k].Mul(bm.cellMatrices[i][k])
     }
+  }
+
+  suffix[bm.Dimension] = gf127.IdentityMatrix()
+  for k := bm.Dimension - 1; k >= 0; k-- {
 
-    bm.ColumnHashes[j] = bm.hashOfString(Hashes)
+    if k == i {
+      suffix[k] = suffix[k + 1]
+    } else {
+      suffix[k] = bm.cellMatrices[i][k].Mul(suffix[k + 1])
+    }
   }
 
-  bm.updateHashOfColumns()
+  bm.RowHashes[i] = hex.EncodeToString(prefix[bm.Dimension].Bytes())
+}
+
+// recomputeColumnMatrix is the column analog of recomputeRowMatrix
+
+func (bm *BlockMatrix) recomputeColumnMatrix( j int ) {
+
+  prefix := bm.colPrefix[j]
+  suffix := bm.colSuffix[j]
+
+  prefix[0] = gf127.IdentityMatrix()
+  for k := 0; k < bm.Dimension; k++ {
+
+    if k == j {
+      prefix[k + 1] = prefix[k]
+    } else {
+      prefix[k + 1] = prefix[k].Mul(bm.cellMatrices[k][j])
+    }
+  }
+
+  suffix[bm.Dimension] = gf127.IdentityMatrix()
+  for k := bm.Dimension - 1; k >= 0; k-- {
+
+    if k == j {
+      suffix[k] = suffix[k + 1]
+    } else {
+      suffix[k] = bm.cellMatrices[k][j].Mul(suffix[k + 1])
+    }
+  }
+
+  bm.ColumnHashes[j] = hex.EncodeToString(prefix[bm.Dimension].Bytes())
+}
+
+// updateRowMatrixAt recomputes RowHashes[i] after only cell (i, ChangedCol)
+// changed. It refreshes the cached prefix products from the changed column
+// on, combines the new row product from the refreshed prefix up to and
+// including the changed column and the untouched suffix after it in one
+// matrix multiply, then refreshes the suffix entries up to the changed
+// column so later calls stay correct. bm.cellMatrices[i][ChangedCol] must
+// already hold the new cell matrix when this is called
+
+func (bm *BlockMatrix) updateRowMatrixAt( i int, ChangedCol int ) {
+
+  prefix := bm.rowPrefix[i]
+  suffix := bm.rowSuffix[i]
+
+  for k := ChangedCol; k < bm.Dimension; k++ {
+
+    if k == i {
+      prefix[k + 1] = prefix[k]
+    } else {
+      prefix[k + 1] = prefix[k].Mul(bm.cellMatrices[i][k])
+    }
+  }
+
+  bm.RowHashes[i] = hex.EncodeToString(prefix[ChangedCol + 1].Mul(suffix[ChangedCol + 1]).Bytes())
+
+  for k := ChangedCol; k >= 0; k-- {
+
+    if k == i {
+      suffix[k] = suffix[k + 1]
+    } else {
+      suffix[k] = bm.cellMatrices[i][k].Mul(suffix[k + 1])
+    }
+  }
+}
+
+// updateColumnMatrixAt is the column analog of updateRowMatrixAt
+
+func (bm *BlockMatrix) updateColumnMatrixAt( j int, ChangedRow int ) {
+
+  prefix := bm.colPrefix[j]
+  suffix := bm.colSuffix[j]
+
+  for k := ChangedRow; k < bm.Dimension; k++ {
+
+    if k == j {
+      prefix[k + 1] = prefix[k]
+    } else {
+      prefix[k + 1] = prefix[k].Mul(bm.cellMatrices[k][j])
+    }
+  }
+
+  bm.ColumnHashes[j] = hex.EncodeToString(prefix[ChangedRow + 1].Mul(suffix[ChangedRow + 1]).Bytes())
+
+  for k := ChangedRow; k >= 0; k-- {
+
+    if k == j {
+      suffix[k] = suffix[k + 1]
+    } else {
+      suffix[k] = bm.cellMatrices[k][j].Mul(suffix[k + 1])
+    }
+  }
 }
 
 // an internal function to update the hash of matrix
@@ -211,21 +601,105 @@ func (bm *BlockMatrix) updateColumnHashes( From int, To int ) {
 
 func (bm *BlockMatrix) updateHashOfMatrix() {
 
-  var Hashes string
+  hr := bm.newHasher()
 
-  Hashes = ""
   for i := 0; i < bm.Dimension; i++ {
 
     for j := 0; j < bm.Dimension; j++ {
 
-      if i == j {
+      if i == j { hr.WriteString(bm.BlockHashes[i][j]) }
+    }
+  }
 
-        Hashes += bm.BlockHashes[i][j]
-      }
+  bm.HashOfMatrix = hr.SumHex()
+}
+
+// an internal function to record a cell's hash after Data is written to it
+// When HashAlgorithm is TZHash this also keeps the cell's GF(2^127) matrix
+// in sync, since that is what row/column hashes are actually combined from.
+// For a Storage-backed matrix, the new hash/cellMatrices entry is only
+// accepted once the bytes themselves are confirmed written: otherwise a
+// rejected PutBlock (e.g. FileStorage refusing to overwrite an
+// already-populated cell) would leave BlockHashes recording a hash for
+// content that was never actually stored anywhere
+
+func (bm *BlockMatrix) setCellHash( i int, j int, Data bytes.Buffer ) {
+
+  if bm.storage != nil {
+
+    if err := bm.storage.PutBlock(i, j, Data.Bytes()); err != nil {
+
+      bm.recordStorageErr(err)
+      return
     }
   }
 
-  bm.HashOfMatrix = bm.hashOfString(Hashes)
+  bm.BlockHashes[i][j] = bm.hashOfBytes(Data)
+
+  if bm.HashAlgorithm == TZHash {
+    bm.cellMatrices[i][j] = gf127.Hash(Data.Bytes())
+  }
+
+  if bm.storage != nil {
+
+    bm.persistCellHash(i, j)
+
+    // the cell now lives in storage; don't also keep it resident in RAM,
+    // that's the whole point of a Storage-backed matrix
+    bm.BlockData[i][j].Reset()
+  }
+}
+
+// meta keys used to persist a BlockMatrix's non-cell state to Storage
+const (
+  metaKeyDimension = "dimension"
+  metaKeyHashAlgorithm = "hashAlgorithm"
+  metaKeyHashOfRows = "hashOfRows"
+  metaKeyHashOfColumns = "hashOfColumns"
+  metaKeyHashOfMatrix = "hashOfMatrix"
+)
+
+func blockHashMetaKey( i int, j int ) string { return fmt.Sprintf("blockHash:%d:%d", i, j) }
+func rowHashMetaKey( i int ) string { return fmt.Sprintf("rowHash:%d", i) }
+func colHashMetaKey( j int ) string { return fmt.Sprintf("colHash:%d", j) }
+
+func mustHexDecode( s string ) []byte {
+
+  b, err := hex.DecodeString(s)
+  if err != nil { return nil }
+
+  return b
+}
+
+// persistCellHash writes a single cell's already-stored hash to bm.storage's
+// meta, recording the first error it hits in bm.storageErr. Called only
+// after the cell's data itself has been successfully written, so a reader
+// never sees a hash meta entry for content that isn't actually there
+
+func (bm *BlockMatrix) persistCellHash( i int, j int ) {
+
+  if err := bm.storage.PutMeta(blockHashMetaKey(i, j), []byte(bm.BlockHashes[i][j])); err != nil { bm.recordStorageErr(err) }
+}
+
+// persistMeta writes the matrix's dimension, hash algorithm, every row and
+// column hash, and the three aggregate hashes to bm.storage
+
+func (bm *BlockMatrix) persistMeta() {
+
+  puts := map[string][]byte{
+    metaKeyDimension: []byte(strconv.Itoa(bm.Dimension)),
+    metaKeyHashAlgorithm: []byte(bm.HashAlgorithm),
+    metaKeyHashOfRows: []byte(bm.HashOfRows),
+    metaKeyHashOfColumns: []byte(bm.HashOfColumns),
+    metaKeyHashOfMatrix: []byte(bm.HashOfMatrix),
+  }
+
+  for i := 0; i < bm.Dimension; i++ { puts[rowHashMetaKey(i)] = []byte(bm.RowHashes[i]) }
+  for j := 0; j < bm.Dimension; j++ { puts[colHashMetaKey(j)] = []byte(bm.ColumnHashes[j]) }
+
+  for key, v := range puts {
+    if err := bm.storage.PutMeta(key, v); err != nil { bm.recordStorageErr(err) }
+  }
 }
 
 // an internal function to fill diagonal cells with random data
@@ -246,7 +720,7 @@ func (bm *BlockMatrix) fillDiagonalWithRandomData() error {
         if err != nil { return err }
 
         bm.BlockData[i][j].Write(RandomData)
-        bm.BlockHashes[i][j] = bm.hashOfBytes(bm.BlockData[i][j])
+        bm.setCellHash(i, j, bm.BlockData[i][j])
 
       }
     }
@@ -257,41 +731,17 @@ func (bm *BlockMatrix) fillDiagonalWithRandomData() error {
 
 // Insert N * N - N blocks into the block matrix
 // Since diagonal cells are filled with random data we have N * N - N available blocks
+// Blocks are written to their cells across bm.effectiveParallelism() workers
 
 func (bm *BlockMatrix) InsertBlocks( Blocks []bytes.Buffer ) {
 
-  var i, j int
-
-  i = 0
-  j = 0
-
-  for b := 0; b < len(Blocks); b++ {
-
-    if i == j {
-
-      i = 0
-      j++
-      b--
-
-    } else if i < j {
-
-      bm.BlockData[i][j] = Blocks[b]
-      bm.BlockHashes[i][j] = bm.hashOfBytes(Blocks[b])
-      i, j = j, i
-
-    } else if i > j {
-
-      bm.BlockData[i][j] = Blocks[b]
-      bm.BlockHashes[i][j] = bm.hashOfBytes(Blocks[b])
-      j++
-      i, j = j, i
-
-    }
-  }
+  bm.parallelForBlocks(insertionTargets(len(Blocks)), Blocks)
 
   bm.updateRowHashes(0, bm.Dimension)
   bm.updateColumnHashes(0, bm.Dimension)
   bm.updateHashOfMatrix()
+
+  if bm.storage != nil { bm.persistMeta() }
 }
 
 // an internal function to return row and column number of a given block
@@ -351,11 +801,50 @@ func (bm *BlockMatrix) GetBlockData( BlockNumber int ) bytes.Buffer {
     return EmptyBuf
   }
 
+  if bm.storage != nil {
+
+    var Buf bytes.Buffer
+
+    data, err := bm.storage.GetBlock(i, j)
+    if err == nil { Buf.Write(data) }
+
+    return Buf
+  }
+
   if TraceEnabled { log.Printf("GetBlockData() returning %v\n", &bm.BlockData[i][j]) }
 
   return bm.BlockData[i][j]
 }
 
+// GetBlockDataReader is the streaming counterpart to GetBlockData: it
+// returns an io.ReadSeekCloser over the block's data instead of a fully
+// loaded bytes.Buffer, so a Storage backend that can stream (SeekableStorage)
+// never has to load a large cell into memory just to read a range of it.
+// The caller is responsible for closing the returned reader
+
+func (bm *BlockMatrix) GetBlockDataReader( BlockNumber int ) ( io.ReadSeekCloser, error ) {
+
+  if TraceEnabled { log.Printf("GetBlockDataReader(%d) called\n", BlockNumber) }
+
+  i, j := bm.blockIndex(BlockNumber)
+
+  if i < 0 || j < 0 || i > bm.Dimension || j > bm.Dimension {
+    return nil, fmt.Errorf("blockmatrix: invalid block number %d", BlockNumber)
+  }
+
+  if bm.storage != nil {
+
+    if seekable, ok := bm.storage.(SeekableStorage); ok { return seekable.OpenBlock(i, j) }
+
+    data, err := bm.storage.GetBlock(i, j)
+    if err != nil { return nil, err }
+
+    return nopCloserReader{bytes.NewReader(data)}, nil
+  }
+
+  return nopCloserReader{bytes.NewReader(bm.BlockData[i][j].Bytes())}, nil
+}
+
 // Given a block number, its hash string is returned
 // If there is no such block, an empty string is returned to the caller
 
@@ -435,8 +924,32 @@ func (bm *BlockMatrix) deleteBlockAt( RowNumber int, ColNumber int ) bool {
 
   bm.BlockData[RowNumber][ColNumber].Reset()
   bm.BlockHashes[RowNumber][ColNumber] = ""
-  bm.updateRowHashes(RowNumber, RowNumber + 1)
-  bm.updateColumnHashes(ColNumber, ColNumber + 1)
+
+  if bm.HashAlgorithm == TZHash {
+
+    // A deleted cell contributes nothing to its row/column product, same
+    // as an excluded diagonal cell, so it becomes the identity matrix.
+    // updateRowMatrixAt/updateColumnMatrixAt then recombine the row and
+    // column hash from the cached prefix/suffix products either side of
+    // (RowNumber, ColNumber) instead of rehashing the whole row and column
+    bm.cellMatrices[RowNumber][ColNumber] = gf127.IdentityMatrix()
+    bm.updateRowMatrixAt(RowNumber, ColNumber)
+    bm.updateColumnMatrixAt(ColNumber, RowNumber)
+    bm.updateHashOfRows()
+    bm.updateHashOfColumns()
+
+  } else {
+
+    bm.updateRowHashes(RowNumber, RowNumber + 1)
+    bm.updateColumnHashes(ColNumber, ColNumber + 1)
+  }
+
+  if bm.storage != nil {
+
+    if err := bm.storage.DeleteBlock(RowNumber, ColNumber); err != nil { bm.recordStorageErr(err) }
+    if err := bm.storage.PutMeta(blockHashMetaKey(RowNumber, ColNumber), []byte("")); err != nil { bm.recordStorageErr(err) }
+    bm.persistMeta()
+  }
 
   return true
 }
@@ -0,0 +1,94 @@
+package blockmatrix
+
+import (
+  "bytes"
+  "fmt"
+  "testing"
+)
+
+func fillDimWithBlocks( dim int ) []bytes.Buffer {
+
+  n := dim*dim - dim
+  blocks := make([]bytes.Buffer, n)
+  for i := range blocks { blocks[i].WriteString(fmt.Sprintf("block-%d", i)) }
+
+  return blocks
+}
+
+func cloneStrings( s []string ) []string {
+
+  return append([]string(nil), s...)
+}
+
+// TestTZHashIncrementalMatchesFullRecompute exercises the incremental
+// row/column update path (updateRowMatrixAt/updateColumnMatrixAt, used by
+// DeleteBlock) against rebuildDerivedState, which recomputes every row and
+// column matrix product from scratch. The two
+// must always agree, since the incremental cache is only a performance
+// optimization over the from-scratch computation
+
+func TestTZHashIncrementalMatchesFullRecompute( t *testing.T ) {
+
+  dim := 6
+  bm := Create(dim, TZHash)
+  bm.InsertBlocks(fillDimWithBlocks(dim))
+
+  bm.DeleteBlock(1)
+  bm.DeleteBlock(5)
+
+  incrementalRowHashes := cloneStrings(bm.RowHashes)
+  incrementalColumnHashes := cloneStrings(bm.ColumnHashes)
+  incrementalHashOfRows := bm.HashOfRows
+  incrementalHashOfColumns := bm.HashOfColumns
+
+  bm.rebuildDerivedState()
+
+  for i := 0; i < dim; i++ {
+
+    if bm.RowHashes[i] != incrementalRowHashes[i] {
+      t.Fatalf("row %d: incremental update %q != full recompute %q", i, incrementalRowHashes[i], bm.RowHashes[i])
+    }
+  }
+
+  for j := 0; j < dim; j++ {
+
+    if bm.ColumnHashes[j] != incrementalColumnHashes[j] {
+      t.Fatalf("column %d: incremental update %q != full recompute %q", j, incrementalColumnHashes[j], bm.ColumnHashes[j])
+    }
+  }
+
+  if bm.HashOfRows != incrementalHashOfRows {
+    t.Fatalf("HashOfRows: incremental update %q != full recompute %q", incrementalHashOfRows, bm.HashOfRows)
+  }
+
+  if bm.HashOfColumns != incrementalHashOfColumns {
+    t.Fatalf("HashOfColumns: incremental update %q != full recompute %q", incrementalHashOfColumns, bm.HashOfColumns)
+  }
+}
+
+// TestInsertBlocksSequentialMatchesParallel checks that InsertBlocks
+// produces identical row/column hashes whether run sequentially (the
+// default Create()) or across multiple workers (CreateWithOptions), for
+// both a SHA-family and the TZHash algorithm
+
+func TestInsertBlocksSequentialMatchesParallel( t *testing.T ) {
+
+  dim := 6
+
+  for _, algo := range []string{Sha256, TZHash} {
+
+    seq := Create(dim, algo)
+    seq.InsertBlocks(fillDimWithBlocks(dim))
+
+    par := CreateWithOptions(dim, algo, Options{Parallelism: 4})
+    par.InsertBlocks(fillDimWithBlocks(dim))
+
+    if seq.GetHashOfRows() != par.GetHashOfRows() {
+      t.Fatalf("%s: sequential HashOfRows %q != parallel %q", algo, seq.GetHashOfRows(), par.GetHashOfRows())
+    }
+
+    if seq.GetHashOfColumns() != par.GetHashOfColumns() {
+      t.Fatalf("%s: sequential HashOfColumns %q != parallel %q", algo, seq.GetHashOfColumns(), par.GetHashOfColumns())
+    }
+  }
+}
@@ -0,0 +1,200 @@
+// Package blockmatrixgob is a Go-only, encoding/gob-based serialization of a
+// blockmatrix.BlockMatrix, intended for replicating a matrix between two
+// processes running this same package and letting the receiver
+// independently verify it.
+//
+// This is NOT a protobuf schema and does not produce a cross-language or
+// cross-Go-version wire format: encoding/gob requires both ends to be Go,
+// encodes type information reflectively, and gives no compatibility
+// guarantee across Go releases. A real blockmatrix.proto with generated
+// bindings (google.golang.org/protobuf) would be needed for that, which
+// requires a go.mod and vendored dependencies this module does not have.
+// Do not treat Marshal's output as a canonical or portable wire format;
+// it is only meant to be read back by this package's own Unmarshal
+//
+// TODO(chunk0-3): the original request called for exactly that real,
+// cross-language protobuf schema plus generated bindings, for replication
+// to nodes that aren't running this Go module. This package does not meet
+// that requirement and isn't a substitute for it; it unblocks the
+// same-process/same-module pieces of chunk0-3 (the Service layer, digest
+// verification) while the protobuf/gRPC work is tracked as a separate
+// follow-up that needs a go.mod and vendored google.golang.org/protobuf
+// (and, for the service, google.golang.org/grpc) before it can land
+package blockmatrixgob
+
+import (
+  "bytes"
+  "encoding/gob"
+  "blockmatrix"
+)
+
+// Cell is the wire representation of a single BlockMatrix entry
+type Cell struct {
+
+  Row int32
+  Col int32
+  Data []byte
+  Hash string
+}
+
+// RowDigest is the wire representation of a single row's hash
+type RowDigest struct {
+
+  Row int32
+  Hash string
+}
+
+// ColumnDigest is the wire representation of a single column's hash
+type ColumnDigest struct {
+
+  Col int32
+  Hash string
+}
+
+// MatrixDigest carries only the aggregate, fixed-size hashes of a matrix,
+// plus its per-row/per-column hashes, for compact cross-node verification.
+// It deliberately excludes cell data
+type MatrixDigest struct {
+
+  Dimension int32
+  HashAlgorithm string
+  RowDigests []RowDigest
+  ColumnDigests []ColumnDigest
+  HashOfRows string
+  HashOfColumns string
+  HashOfMatrix string
+}
+
+// Message is the full wire representation of a BlockMatrix, including
+// every cell (the random diagonal included)
+type Message struct {
+
+  Dimension int32
+  HashAlgorithm string
+  Cells []Cell
+  Digest MatrixDigest
+}
+
+// Marshal serializes the full state of bm: every cell (including the
+// random diagonal) plus the aggregated row/column/matrix digests
+
+func Marshal( bm *blockmatrix.BlockMatrix ) ( []byte, error ) {
+
+  msg := Message{
+    Dimension: int32(bm.Dimension),
+    HashAlgorithm: bm.HashAlgorithm,
+    Digest: digestOf(bm),
+  }
+
+  for i := 0; i < bm.Dimension; i++ {
+
+    for j := 0; j < bm.Dimension; j++ {
+
+      hash := bm.BlockHashes[i][j]
+      if hash == "" && i != j { continue } // deleted or never written, skip
+
+      msg.Cells = append(msg.Cells, Cell{
+        Row: int32(i),
+        Col: int32(j),
+        Data: bm.BlockData[i][j].Bytes(),
+        Hash: hash,
+      })
+    }
+  }
+
+  var buf bytes.Buffer
+  if err := gob.NewEncoder(&buf).Encode(msg); err != nil { return nil, err }
+
+  return buf.Bytes(), nil
+}
+
+// MarshalDigest serializes only the compact, fixed-size digest of bm:
+// HashOfRows, HashOfColumns, HashOfMatrix and the per-row/per-column
+// hashes, with no cell data at all
+
+func MarshalDigest( bm *blockmatrix.BlockMatrix ) ( []byte, error ) {
+
+  var buf bytes.Buffer
+  if err := gob.NewEncoder(&buf).Encode(digestOf(bm)); err != nil { return nil, err }
+
+  return buf.Bytes(), nil
+}
+
+func digestOf( bm *blockmatrix.BlockMatrix ) MatrixDigest {
+
+  d := MatrixDigest{
+    Dimension: int32(bm.Dimension),
+    HashAlgorithm: bm.HashAlgorithm,
+    HashOfRows: bm.HashOfRows,
+    HashOfColumns: bm.HashOfColumns,
+    HashOfMatrix: bm.HashOfMatrix,
+  }
+
+  for i := 0; i < bm.Dimension; i++ { d.RowDigests = append(d.RowDigests, RowDigest{Row: int32(i), Hash: bm.GetRowHash(i)}) }
+  for j := 0; j < bm.Dimension; j++ { d.ColumnDigests = append(d.ColumnDigests, ColumnDigest{Col: int32(j), Hash: bm.GetColHash(j)}) }
+
+  return d
+}
+
+// Unmarshal parses a Message produced by Marshal and rebuilds a full
+// BlockMatrix from it via blockmatrix.FromState, which recomputes the
+// row/column hashes and HashOfMatrix (and, for TZHash, the cell matrix
+// caches) from the restored cell data rather than trusting the digest
+// fields. The Message's digest is ignored, not checked: a tampered digest is
+// not reported, the rebuilt matrix simply carries the recomputed hashes
+
+func Unmarshal( data []byte ) ( *blockmatrix.BlockMatrix, error ) {
+
+  var msg Message
+  if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&msg); err != nil { return nil, err }
+
+  Dimension := int(msg.Dimension)
+
+  BlockData := make([][]bytes.Buffer, Dimension)
+  BlockHashes := make([][]string, Dimension)
+  for i := 0; i < Dimension; i++ {
+    BlockData[i] = make([]bytes.Buffer, Dimension)
+    BlockHashes[i] = make([]string, Dimension)
+  }
+
+  for _, cell := range msg.Cells {
+
+    BlockData[cell.Row][cell.Col].Write(cell.Data)
+    BlockHashes[cell.Row][cell.Col] = cell.Hash
+  }
+
+  return blockmatrix.FromState(Dimension, msg.HashAlgorithm, BlockData, BlockHashes), nil
+}
+
+// UnmarshalDigest parses a MatrixDigest produced by MarshalDigest
+
+func UnmarshalDigest( data []byte ) ( MatrixDigest, error ) {
+
+  var d MatrixDigest
+  err := gob.NewDecoder(bytes.NewReader(data)).Decode(&d)
+  return d, err
+}
+
+// Verify reports whether digest is consistent with bm: every row hash,
+// column hash and the three aggregate hashes must match exactly
+
+func Verify( bm *blockmatrix.BlockMatrix, digest MatrixDigest ) bool {
+
+  if int(digest.Dimension) != bm.Dimension { return false }
+  if digest.HashAlgorithm != bm.HashAlgorithm { return false }
+  if digest.HashOfRows != bm.HashOfRows { return false }
+  if digest.HashOfColumns != bm.HashOfColumns { return false }
+  if digest.HashOfMatrix != bm.HashOfMatrix { return false }
+
+  if len(digest.RowDigests) != bm.Dimension || len(digest.ColumnDigests) != bm.Dimension { return false }
+
+  for _, rd := range digest.RowDigests {
+    if bm.GetRowHash(int(rd.Row)) != rd.Hash { return false }
+  }
+
+  for _, cd := range digest.ColumnDigests {
+    if bm.GetColHash(int(cd.Col)) != cd.Hash { return false }
+  }
+
+  return true
+}
@@ -0,0 +1,63 @@
+package blockmatrixgob
+
+import (
+  "bytes"
+  "testing"
+
+  "blockmatrix"
+)
+
+func fillDimWithBlocks( dim int ) []bytes.Buffer {
+
+  blocks := make([]bytes.Buffer, dim*dim-dim)
+  for i := range blocks { blocks[i].WriteString("block") }
+
+  return blocks
+}
+
+func TestMarshalUnmarshalRoundTrip( t *testing.T ) {
+
+  dim := 4
+  bm := blockmatrix.Create(dim, blockmatrix.Sha256)
+  bm.InsertBlocks(fillDimWithBlocks(dim))
+
+  data, err := Marshal(bm)
+  if err != nil { t.Fatalf("Marshal: %v", err) }
+
+  got, err := Unmarshal(data)
+  if err != nil { t.Fatalf("Unmarshal: %v", err) }
+
+  if got.GetHashOfRows() != bm.GetHashOfRows() {
+    t.Fatalf("HashOfRows after round trip %q != original %q", got.GetHashOfRows(), bm.GetHashOfRows())
+  }
+
+  if got.GetHashOfColumns() != bm.GetHashOfColumns() {
+    t.Fatalf("HashOfColumns after round trip %q != original %q", got.GetHashOfColumns(), bm.GetHashOfColumns())
+  }
+
+  if got.HashOfMatrix != bm.HashOfMatrix {
+    t.Fatalf("HashOfMatrix after round trip %q != original %q", got.HashOfMatrix, bm.HashOfMatrix)
+  }
+}
+
+func TestMarshalDigestVerify( t *testing.T ) {
+
+  dim := 4
+  bm := blockmatrix.Create(dim, blockmatrix.Sha256)
+  bm.InsertBlocks(fillDimWithBlocks(dim))
+
+  data, err := MarshalDigest(bm)
+  if err != nil { t.Fatalf("MarshalDigest: %v", err) }
+
+  digest, err := UnmarshalDigest(data)
+  if err != nil { t.Fatalf("UnmarshalDigest: %v", err) }
+
+  if !Verify(bm, digest) {
+    t.Fatalf("Verify rejected a digest taken from the same matrix")
+  }
+
+  digest.HashOfRows = "not-the-real-hash"
+  if Verify(bm, digest) {
+    t.Fatalf("Verify accepted a tampered HashOfRows")
+  }
+}
@@ -0,0 +1,142 @@
+// Package blockmatrixsvc exposes a BlockMatrix over HTTP so a matrix on
+// one node can be replicated to and independently verified by another.
+//
+// A real deployment would likely want this as a gRPC service instead, but
+// grpc-go and its protobuf runtime are third-party dependencies and this
+// module has no go.mod/vendoring to pull them in yet, so this is a plain
+// net/http service (stdlib only) exposing the same four operations a gRPC
+// service would: Insert, Delete, GetBlock and Verify.
+//
+// TODO(chunk0-3): this does not satisfy the original request's
+// cross-language replication goal on its own, since it carries
+// blockmatrixgob's Go-only wire format rather than protobuf. Treat this
+// service as a stopgap for same-module callers; the gRPC/protobuf version
+// is a tracked follow-up, not something this package should be mistaken for
+package blockmatrixsvc
+
+import (
+  "bytes"
+  "encoding/json"
+  "net/http"
+  "strconv"
+  "sync"
+
+  "blockmatrix"
+  "blockmatrix/blockmatrixgob"
+)
+
+// Service wraps a BlockMatrix and serves it over HTTP. BlockMatrix itself
+// keeps no internal lock, and net/http runs every request in its own
+// goroutine, so Service guards every access to bm with mu: handleInsert/
+// handleDelete take the write lock since they mutate BlockHashes/RowHashes/
+// ColumnHashes/BlockData, and handleGetBlock/handleVerify take the read
+// lock since they only read that state
+type Service struct {
+
+  mu sync.RWMutex
+  bm *blockmatrix.BlockMatrix
+}
+
+// New returns a Service backed by bm
+
+func New( bm *blockmatrix.BlockMatrix ) *Service {
+
+  return &Service{bm: bm}
+}
+
+// Handler returns an http.Handler with all of the service's routes
+// registered on a fresh ServeMux
+
+func (s *Service) Handler() http.Handler {
+
+  mux := http.NewServeMux()
+
+  mux.HandleFunc("/insert", s.handleInsert)
+  mux.HandleFunc("/delete/", s.handleDelete)
+  mux.HandleFunc("/block/", s.handleGetBlock)
+  mux.HandleFunc("/verify", s.handleVerify)
+
+  return mux
+}
+
+// insertRequest is the JSON body accepted by POST /insert: a flat list of
+// blocks to be handed to BlockMatrix.InsertBlocks in order
+type insertRequest struct {
+
+  Blocks [][]byte `json:"blocks"`
+}
+
+func (s *Service) handleInsert( w http.ResponseWriter, r *http.Request ) {
+
+  if r.Method != http.MethodPost { http.Error(w, "method not allowed", http.StatusMethodNotAllowed); return }
+
+  var req insertRequest
+  if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, err.Error(), http.StatusBadRequest); return }
+
+  Blocks := make([]bytes.Buffer, len(req.Blocks))
+  for i, b := range req.Blocks { Blocks[i].Write(b) }
+
+  s.mu.Lock()
+  s.bm.InsertBlocks(Blocks)
+  err := s.bm.StorageErr()
+  s.mu.Unlock()
+
+  if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) handleDelete( w http.ResponseWriter, r *http.Request ) {
+
+  if r.Method != http.MethodPost { http.Error(w, "method not allowed", http.StatusMethodNotAllowed); return }
+
+  BlockNumber, err := strconv.Atoi(r.URL.Path[len("/delete/"):])
+  if err != nil { http.Error(w, "invalid block number", http.StatusBadRequest); return }
+
+  s.mu.Lock()
+  deleted := s.bm.DeleteBlock(BlockNumber)
+  err = s.bm.StorageErr()
+  s.mu.Unlock()
+
+  if !deleted { http.Error(w, "no such block", http.StatusNotFound); return }
+  if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) handleGetBlock( w http.ResponseWriter, r *http.Request ) {
+
+  if r.Method != http.MethodGet { http.Error(w, "method not allowed", http.StatusMethodNotAllowed); return }
+
+  BlockNumber, err := strconv.Atoi(r.URL.Path[len("/block/"):])
+  if err != nil { http.Error(w, "invalid block number", http.StatusBadRequest); return }
+
+  s.mu.RLock()
+  data := s.bm.GetBlockData(BlockNumber)
+  s.mu.RUnlock()
+
+  w.Header().Set("Content-Type", "application/octet-stream")
+  w.Write(data.Bytes())
+}
+
+// handleVerify accepts a MatrixDigest as a gob-encoded body (the format
+// produced by blockmatrixgob.MarshalDigest) and reports whether it matches
+// this service's matrix
+
+func (s *Service) handleVerify( w http.ResponseWriter, r *http.Request ) {
+
+  if r.Method != http.MethodPost { http.Error(w, "method not allowed", http.StatusMethodNotAllowed); return }
+
+  body := new(bytes.Buffer)
+  if _, err := body.ReadFrom(r.Body); err != nil { http.Error(w, err.Error(), http.StatusBadRequest); return }
+
+  digest, err := blockmatrixgob.UnmarshalDigest(body.Bytes())
+  if err != nil { http.Error(w, err.Error(), http.StatusBadRequest); return }
+
+  s.mu.RLock()
+  ok := blockmatrixgob.Verify(s.bm, digest)
+  s.mu.RUnlock()
+
+  w.Header().Set("Content-Type", "application/json")
+  json.NewEncoder(w).Encode(map[string]bool{"verified": ok})
+}
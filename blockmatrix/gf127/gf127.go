@@ -0,0 +1,233 @@
+// Package gf127 implements arithmetic in GF(2^127), the binary field used by
+// the Tillich-Zemor style homomorphic hash scheme (blockmatrix.TZHash).
+//
+// Elements are represented as the coefficients of a degree-126 polynomial
+// over GF(2), reduced modulo the irreducible trinomial x^127 + x^63 + 1.
+// Addition is bitwise XOR and multiplication is carry-less (polynomial)
+// multiplication followed by reduction.
+//
+// WARNING: A0/A1 below are the original "natural" Tillich-Zemor generator
+// pair (the companion matrices for multiplication by x and x + 1). Grassl,
+// Ilic, Magliveras and Steinwandt (2011) published an efficient algorithm
+// for finding collisions under exactly this generator pair, so Hash does
+// not provide collision resistance. See the TZHash doc comment in the
+// blockmatrix package for what this means for BlockMatrix's integrity
+// guarantees
+package gf127
+
+import (
+  "encoding/hex"
+  "math/big"
+)
+
+// Bits is the degree of the field, i.e. elements are polynomials of degree < Bits
+const Bits = 127
+
+// modulus is x^127 + x^63 + 1, the reduction polynomial for the field
+var modulus = func() *big.Int {
+  m := new(big.Int)
+  m.SetBit(m, 127, 1)
+  m.SetBit(m, 63, 1)
+  m.SetBit(m, 0, 1)
+  return m
+}()
+
+// GF127 is a single element of GF(2^127)
+type GF127 struct {
+  n big.Int
+}
+
+// Zero returns the additive identity of the field
+func Zero() GF127 {
+  return GF127{}
+}
+
+// One returns the multiplicative identity of the field
+func One() GF127 {
+  return FromUint64(1)
+}
+
+// FromUint64 builds a field element from a 64-bit integer
+func FromUint64( v uint64 ) GF127 {
+
+  var e GF127
+  e.n.SetUint64(v)
+  return e
+}
+
+// FromBytes builds a field element from a big-endian byte slice, clearing
+// bit 127. The input is not reduced modulo the field polynomial, so only
+// inputs of at most 16 bytes (such as those produced by Bytes) yield a
+// valid element
+
+func FromBytes( b []byte ) GF127 {
+
+  var e GF127
+  e.n.SetBytes(b)
+  e.n.SetBit(&e.n, Bits, 0)
+  return e
+}
+
+// Bytes returns the element as a fixed-length 16 byte big-endian slice
+
+func (a GF127) Bytes() []byte {
+
+  return a.n.FillBytes(make([]byte, 16))
+}
+
+// String returns the hex encoding of the element
+
+func (a GF127) String() string {
+
+  return hex.EncodeToString(a.Bytes())
+}
+
+// Equal reports whether two field elements are the same
+
+func (a GF127) Equal( b GF127 ) bool {
+
+  return a.n.Cmp(&b.n) == 0
+}
+
+// Add returns a + b, which in a binary field is simply XOR
+
+func (a GF127) Add( b GF127 ) GF127 {
+
+  var r GF127
+  r.n.Xor(&a.n, &b.n)
+  return r
+}
+
+// Mul returns a * b using carry-less multiplication reduced modulo
+// x^127 + x^63 + 1
+
+func (a GF127) Mul( b GF127 ) GF127 {
+
+  result := new(big.Int)
+  aa := new(big.Int).Set(&a.n)
+  bb := new(big.Int).Set(&b.n)
+
+  for bb.Sign() != 0 {
+
+    if bb.Bit(0) == 1 {
+      result.Xor(result, aa)
+    }
+
+    aa.Lsh(aa, 1)
+    if aa.Bit(Bits) == 1 {
+      aa.Xor(aa, modulus)
+    }
+
+    bb.Rsh(bb, 1)
+  }
+
+  var r GF127
+  r.n.Set(result)
+  return r
+}
+
+// Matrix is a 2x2 matrix over GF(2^127), represented as [[A, B], [C, D]]
+type Matrix struct {
+  A, B, C, D GF127
+}
+
+// IdentityMatrix returns the 2x2 identity matrix
+
+func IdentityMatrix() Matrix {
+
+  return Matrix{A: One(), D: One()}
+}
+
+// Mul returns the matrix product m * o. Matrix multiplication over this
+// field is associative but not commutative, which is what makes the
+// resulting hash homomorphic under concatenation but order-sensitive
+
+func (m Matrix) Mul( o Matrix ) Matrix {
+
+  return Matrix{
+    A: m.A.Mul(o.A).Add(m.B.Mul(o.C)),
+    B: m.A.Mul(o.B).Add(m.B.Mul(o.D)),
+    C: m.C.Mul(o.A).Add(m.D.Mul(o.C)),
+    D: m.C.Mul(o.B).Add(m.D.Mul(o.D)),
+  }
+}
+
+// Equal reports whether two matrices are the same
+
+func (m Matrix) Equal( o Matrix ) bool {
+
+  return m.A.Equal(o.A) && m.B.Equal(o.B) && m.C.Equal(o.C) && m.D.Equal(o.D)
+}
+
+// Bytes serializes the matrix as the concatenation of its four entries,
+// 16 bytes each, for a fixed 64 byte digest
+
+func (m Matrix) Bytes() []byte {
+
+  out := make([]byte, 0, 64)
+  out = append(out, m.A.Bytes()...)
+  out = append(out, m.B.Bytes()...)
+  out = append(out, m.C.Bytes()...)
+  out = append(out, m.D.Bytes()...)
+  return out
+}
+
+// MatrixFromBytes parses the 64 byte serialization produced by Matrix.Bytes
+// back into a Matrix, e.g. when reloading a persisted BlockMatrix
+
+func MatrixFromBytes( b []byte ) Matrix {
+
+  return Matrix{
+    A: FromBytes(b[0:16]),
+    B: FromBytes(b[16:32]),
+    C: FromBytes(b[32:48]),
+    D: FromBytes(b[48:64]),
+  }
+}
+
+// xElem and onePlusXElem are the field elements corresponding to the
+// polynomials x and x + 1, used to build the two generator matrices below
+var (
+  xElem       = FromUint64(2)
+  onePlusXElem = FromUint64(3)
+)
+
+// A0 and A1 are the two fixed, non-commuting SL(2, GF(2^127)) generator
+// matrices used by Hash. They are the classic Tillich-Zemor companion
+// matrices for multiplication by x and by x + 1.
+//
+// WARNING: this is the specific generator pair Grassl/Ilic/Magliveras/
+// Steinwandt showed an efficient collision-finding algorithm for, so Hash
+// built from A0/A1 is homomorphic but not collision-resistant
+
+var (
+  A0 = Matrix{A: xElem, B: One(), C: One(), D: Zero()}
+  A1 = Matrix{A: onePlusXElem, B: One(), C: One(), D: Zero()}
+)
+
+// Hash computes the Tillich-Zemor style homomorphic hash of data: starting
+// from the identity matrix, it walks the bits of data most-significant-bit
+// first and multiplies in A0 for a 0 bit or A1 for a 1 bit. Because matrix
+// multiplication is associative, the hash of the concatenation of two
+// byte strings is the product of their individual hash matrices, which is
+// what lets row and column hashes be combined without touching untouched
+// cells
+
+func Hash( data []byte ) Matrix {
+
+  h := IdentityMatrix()
+
+  for _, byteVal := range data {
+
+    for bit := 7; bit >= 0; bit-- {
+
+      if (byteVal>>uint(bit))&1 == 0 {
+        h = h.Mul(A0)
+      } else {
+        h = h.Mul(A1)
+      }
+    }
+  }
+
+  return h
+}
@@ -0,0 +1,140 @@
+package gf127
+
+import "testing"
+
+func TestAddIdentityAndSelfInverse( t *testing.T ) {
+
+  a := FromUint64(12345)
+
+  if !a.Add(Zero()).Equal(a) {
+    t.Fatalf("a + 0 != a")
+  }
+
+  if !a.Add(a).Equal(Zero()) {
+    t.Fatalf("a + a != 0, addition should be XOR in a binary field")
+  }
+}
+
+func TestAddCommutativeAndAssociative( t *testing.T ) {
+
+  a := FromUint64(7)
+  b := FromUint64(99)
+  c := FromUint64(0xABCDEF)
+
+  if !a.Add(b).Equal(b.Add(a)) {
+    t.Fatalf("a + b != b + a")
+  }
+
+  if !a.Add(b).Add(c).Equal(a.Add(b.Add(c))) {
+    t.Fatalf("(a + b) + c != a + (b + c)")
+  }
+}
+
+func TestMulIdentity( t *testing.T ) {
+
+  a := FromUint64(424242)
+
+  if !a.Mul(One()).Equal(a) {
+    t.Fatalf("a * 1 != a")
+  }
+
+  if !a.Mul(Zero()).Equal(Zero()) {
+    t.Fatalf("a * 0 != 0")
+  }
+}
+
+func TestMulCommutativeAndAssociative( t *testing.T ) {
+
+  a := FromUint64(3)
+  b := FromUint64(5)
+  c := FromUint64(17)
+
+  if !a.Mul(b).Equal(b.Mul(a)) {
+    t.Fatalf("field multiplication should be commutative: a * b != b * a")
+  }
+
+  if !a.Mul(b).Mul(c).Equal(a.Mul(b.Mul(c))) {
+    t.Fatalf("(a * b) * c != a * (b * c)")
+  }
+}
+
+func TestDistributive( t *testing.T ) {
+
+  a := FromUint64(9)
+  b := FromUint64(21)
+  c := FromUint64(0x12345)
+
+  lhs := a.Mul(b.Add(c))
+  rhs := a.Mul(b).Add(a.Mul(c))
+
+  if !lhs.Equal(rhs) {
+    t.Fatalf("a * (b + c) != a*b + a*c")
+  }
+}
+
+func TestBytesRoundTrip( t *testing.T ) {
+
+  a := FromUint64(0xDEADBEEF)
+
+  b := FromBytes(a.Bytes())
+  if !a.Equal(b) {
+    t.Fatalf("FromBytes(a.Bytes()) != a")
+  }
+}
+
+func TestMatrixIdentity( t *testing.T ) {
+
+  m := Matrix{A: FromUint64(3), B: FromUint64(5), C: FromUint64(7), D: FromUint64(11)}
+  id := IdentityMatrix()
+
+  if !m.Mul(id).Equal(m) {
+    t.Fatalf("m * I != m")
+  }
+
+  if !id.Mul(m).Equal(m) {
+    t.Fatalf("I * m != m")
+  }
+}
+
+func TestMatrixBytesRoundTrip( t *testing.T ) {
+
+  m := Matrix{A: FromUint64(1), B: FromUint64(2), C: FromUint64(3), D: FromUint64(4)}
+
+  got := MatrixFromBytes(m.Bytes())
+  if !got.Equal(m) {
+    t.Fatalf("MatrixFromBytes(m.Bytes()) != m")
+  }
+}
+
+func TestGeneratorsNonCommuting( t *testing.T ) {
+
+  // A0 and A1 not commuting is what makes Hash order-sensitive
+  if A0.Mul(A1).Equal(A1.Mul(A0)) {
+    t.Fatalf("A0 and A1 should not commute")
+  }
+}
+
+func TestHashIsHomomorphicUnderConcatenation( t *testing.T ) {
+
+  left := []byte("left-half")
+  right := []byte("right-half")
+
+  combined := append(append([]byte{}, left...), right...)
+
+  got := Hash(combined)
+  want := Hash(left).Mul(Hash(right))
+
+  if !got.Equal(want) {
+    t.Fatalf("Hash(left || right) != Hash(left) * Hash(right)")
+  }
+}
+
+func TestHashIsOrderSensitive( t *testing.T ) {
+
+  a := Hash([]byte("ab"))
+  b := Hash([]byte("ba"))
+
+  if a.Equal(b) {
+    t.Fatalf("Hash(\"ab\") should differ from Hash(\"ba\"), matrix multiplication is non-commutative")
+  }
+}
@@ -0,0 +1,102 @@
+package blockmatrix
+
+import (
+  "crypto/sha256"
+  "crypto/sha512"
+  "fmt"
+  "hash"
+)
+
+// hasher wraps a hash.Hash so row/column/matrix hash computation can feed
+// each per-cell hex hash straight into a running digest instead of first
+// concatenating them into one big string. A nil inner hash.Hash (an
+// unrecognized HashAlgorithm and no Options.Hasher override) makes every
+// write a no-op and SumHex return "", matching newHasherFor's behavior for
+// an unknown algorithm
+
+type hasher struct {
+
+  h hash.Hash
+}
+
+// Write implements io.Writer
+
+func (hr *hasher) Write( p []byte ) ( int, error ) {
+
+  if hr.h == nil { return len(p), nil }
+
+  return hr.h.Write(p)
+}
+
+// WriteString implements io.StringWriter
+
+func (hr *hasher) WriteString( s string ) ( int, error ) {
+
+  return hr.Write([]byte(s))
+}
+
+// WriteByte implements io.ByteWriter
+
+func (hr *hasher) WriteByte( b byte ) error {
+
+  _, err := hr.Write([]byte{b})
+  return err
+}
+
+// SumHex returns the hex encoding of the running digest
+
+func (hr *hasher) SumHex() string {
+
+  if hr.h == nil { return "" }
+
+  return fmt.Sprintf("%x", hr.h.Sum(nil))
+}
+
+// Options configures CreateWithOptions
+
+type Options struct {
+
+  // Parallelism is how many goroutines InsertBlocks and the row/column
+  // hash recomputation pass may use. <= 0 means runtime.NumCPU()
+  Parallelism int
+
+  // Hasher, if set, overrides HashAlgorithm for every SHA-family hash this
+  // matrix computes (block hashes and, for TZHash, the aggregate hashes
+  // built on top of the GF(2^127) matrix products), letting a caller plug
+  // in BLAKE3 or a hardware-accelerated implementation. It does not affect
+  // TZHash's own per-block homomorphic hash, which is always the GF(2^127)
+  // matrix product
+  Hasher func() hash.Hash
+}
+
+// newHasher builds the hasher this matrix should use for its next
+// SHA-family digest: bm.hasherFunc if Options.Hasher was set, otherwise
+// whatever bm.HashAlgorithm maps to
+
+func (bm *BlockMatrix) newHasher() *hasher {
+
+  return newHasherFor(bm.hasherFunc, bm.HashAlgorithm)
+}
+
+// newHasherFor is newHasher's algorithm-selection logic without a
+// BlockMatrix to hang it off of, so proof verification can rebuild the same
+// hasher a Proof's source matrix used, HasherFunc override included
+
+func newHasherFor( HasherFunc func() hash.Hash, HashAlgorithm string ) *hasher {
+
+  if HasherFunc != nil { return &hasher{h: HasherFunc()} }
+
+  switch HashAlgorithm {
+
+  case Sha256:
+    return &hasher{h: sha256.New()}
+  case Sha384:
+    return &hasher{h: sha512.New384()}
+  case Sha512:
+    return &hasher{h: sha512.New()}
+  case TZHash:
+    return &hasher{h: sha256.New()}
+  default:
+    return &hasher{h: nil}
+  }
+}
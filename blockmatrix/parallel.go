@@ -0,0 +1,89 @@
+package blockmatrix
+
+import (
+  "bytes"
+  "sync"
+)
+
+// parallelFor calls fn(i) for every i in [From, To), using up to Parallelism
+// goroutines. Parallelism <= 1, or a range of one or zero elements, runs fn
+// sequentially in increasing order instead of spinning up a worker pool,
+// which is what every plain Create()/CreateWithStorage() matrix does
+
+func parallelFor( From int, To int, Parallelism int, fn func( i int ) ) {
+
+  if Parallelism <= 1 || To - From <= 1 {
+
+    for i := From; i < To; i++ { fn(i) }
+    return
+  }
+
+  workers := Parallelism
+  if workers > To - From { workers = To - From }
+
+  work := make(chan int)
+  var wg sync.WaitGroup
+
+  for w := 0; w < workers; w++ {
+
+    wg.Add(1)
+    go func() {
+
+      defer wg.Done()
+      for i := range work { fn(i) }
+    }()
+  }
+
+  for i := From; i < To; i++ { work <- i }
+  close(work)
+
+  wg.Wait()
+}
+
+// insertionTargets replays InsertBlocks' original row/column walk purely to
+// compute which (i, j) cell each of n blocks lands in, without touching a
+// BlockMatrix, so the actual cell writes can be dispatched across workers
+
+func insertionTargets( n int ) [][2]int {
+
+  targets := make([][2]int, 0, n)
+  i, j := 0, 0
+
+  for len(targets) < n {
+
+    if i == j {
+
+      i = 0
+      j++
+
+    } else if i < j {
+
+      targets = append(targets, [2]int{i, j})
+      i, j = j, i
+
+    } else {
+
+      targets = append(targets, [2]int{i, j})
+      j++
+      i, j = j, i
+    }
+  }
+
+  return targets
+}
+
+// parallelForBlocks writes Blocks into bm at the cells insertionTargets
+// computed for them, across bm.effectiveParallelism() workers. Each worker
+// only ever touches the cell its own target names, so this is safe even
+// when bm is storage-backed
+
+func (bm *BlockMatrix) parallelForBlocks( targets [][2]int, Blocks []bytes.Buffer ) {
+
+  parallelFor(0, len(targets), bm.effectiveParallelism(), func( b int ) {
+
+    i, j := targets[b][0], targets[b][1]
+
+    bm.BlockData[i][j] = Blocks[b]
+    bm.setCellHash(i, j, Blocks[b])
+  })
+}
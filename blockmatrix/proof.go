@@ -0,0 +1,298 @@
+package blockmatrix
+
+import (
+  "encoding/hex"
+  "fmt"
+  "hash"
+
+  "blockmatrix/gf127"
+)
+
+// Proof is a compact witness that a given block was present in a
+// BlockMatrix at a particular row/column, without shipping the rest of
+// the matrix. It carries exactly what's needed to recompute RowHashes[Row]
+// and ColumnHashes[Col] from the claimed block hash plus its siblings, and
+// from there HashOfRows and HashOfColumns
+type Proof struct {
+
+  Row int
+  Col int
+  Dimension int
+  HashAlgorithm string
+
+  // HasherFunc mirrors Options.Hasher on the BlockMatrix this proof was
+  // taken from: nil for a matrix built with plain Create/CreateWithStorage
+  // or a bare CreateWithOptions{}, or the override used by a matrix built
+  // with CreateWithOptions{Hasher: ...}. Verification needs it to rebuild
+  // the exact same hash.Hash the source matrix used for its SHA-family
+  // digests. Since it's a func value, a Proof with HasherFunc set can only
+  // be verified in the same process that built it, not after being
+  // serialized and sent elsewhere
+  HasherFunc func() hash.Hash
+
+  // RowSiblings is row Row's BlockHashes in increasing column order,
+  // excluding the diagonal cell (column Row) and the cell being proven
+  // (column Col). ColSiblings is the column analog
+  RowSiblings []string
+  ColSiblings []string
+
+  // RowHashes and ColumnHashes are the full current hash lists, needed to
+  // recompute HashOfRows/HashOfColumns and to check that only row Row and
+  // column Col actually changed across a deletion (see DeletionProof)
+  RowHashes []string
+  ColumnHashes []string
+
+  HashOfRows string
+  HashOfColumns string
+  HashOfMatrix string
+}
+
+// GetInclusionProof builds a Proof for the cell holding BlockNumber in its
+// current state. The proof is equally valid for a present block (pass its
+// hash to VerifyInclusionProof) or a deleted one (pass "")
+
+func (bm *BlockMatrix) GetInclusionProof( BlockNumber int ) ( Proof, error ) {
+
+  i, j := bm.blockIndex(BlockNumber)
+
+  if i < 0 || j < 0 || i > bm.Dimension || j > bm.Dimension {
+    return Proof{}, fmt.Errorf("blockmatrix: invalid block number %d", BlockNumber)
+  }
+
+  var RowSiblings, ColSiblings []string
+
+  for k := 0; k < bm.Dimension; k++ {
+    if k != i && k != j { RowSiblings = append(RowSiblings, bm.BlockHashes[i][k]) }
+  }
+
+  for k := 0; k < bm.Dimension; k++ {
+    if k != j && k != i { ColSiblings = append(ColSiblings, bm.BlockHashes[k][j]) }
+  }
+
+  return Proof{
+    Row: i,
+    Col: j,
+    Dimension: bm.Dimension,
+    HashAlgorithm: bm.HashAlgorithm,
+    HasherFunc: bm.hasherFunc,
+    RowSiblings: RowSiblings,
+    ColSiblings: ColSiblings,
+    RowHashes: append([]string(nil), bm.RowHashes...),
+    ColumnHashes: append([]string(nil), bm.ColumnHashes...),
+    HashOfRows: bm.HashOfRows,
+    HashOfColumns: bm.HashOfColumns,
+    HashOfMatrix: bm.HashOfMatrix,
+  }, nil
+}
+
+// VerifyInclusionProof checks that blockHash, combined with proof's
+// siblings, reproduces proof.RowHashes[proof.Row] and
+// proof.ColumnHashes[proof.Col]; that those lists in turn reproduce
+// proof.HashOfRows/HashOfColumns; and that proof.HashOfMatrix matches
+// matrixDigest, the caller's previously-trusted digest for this matrix
+// instance (HashOfMatrix only covers the diagonal, so it doesn't change
+// across ordinary insertions or GDPR deletions and can be pinned once)
+
+func VerifyInclusionProof( proof Proof, blockHash string, matrixDigest string ) bool {
+
+  if proof.Row < 0 || proof.Col < 0 || proof.Row >= proof.Dimension || proof.Col >= proof.Dimension { return false }
+  if len(proof.RowSiblings) != proof.Dimension - 2 { return false }
+  if len(proof.ColSiblings) != proof.Dimension - 2 { return false }
+  if len(proof.RowHashes) != proof.Dimension || len(proof.ColumnHashes) != proof.Dimension { return false }
+
+  rowCells := reconstructRowCells(proof, blockHash)
+  if computeRowHashFromCells(proof.HashAlgorithm, proof.HasherFunc, proof.Dimension, proof.Row, rowCells) != proof.RowHashes[proof.Row] { return false }
+
+  colCells := reconstructColCells(proof, blockHash)
+  if computeColHashFromCells(proof.HashAlgorithm, proof.HasherFunc, proof.Dimension, proof.Col, colCells) != proof.ColumnHashes[proof.Col] { return false }
+
+  if combineHashList(proof.HashAlgorithm, proof.HasherFunc, proof.RowHashes) != proof.HashOfRows { return false }
+  if combineHashList(proof.HashAlgorithm, proof.HasherFunc, proof.ColumnHashes) != proof.HashOfColumns { return false }
+
+  return proof.HashOfMatrix == matrixDigest
+}
+
+// DeletionProof bundles the inclusion proof of a cell from just before a
+// GDPR deletion with one from just after, so a verifier can see that
+// exactly one cell went from a given hash to empty while the rest of the
+// row, column and matrix (diagonal randomness included) stayed put
+type DeletionProof struct {
+
+  Before Proof
+  After Proof
+}
+
+// GetDeletionProof pairs Before - an inclusion proof the caller must have
+// captured with GetInclusionProof before calling DeleteBlock, since once a
+// cell is erased its prior hash can no longer be recovered from the
+// matrix - with a fresh inclusion proof of BlockNumber's now-empty cell
+
+func (bm *BlockMatrix) GetDeletionProof( BlockNumber int, Before Proof ) ( DeletionProof, error ) {
+
+  After, err := bm.GetInclusionProof(BlockNumber)
+  if err != nil { return DeletionProof{}, err }
+
+  if After.Row != Before.Row || After.Col != Before.Col {
+    return DeletionProof{}, fmt.Errorf("blockmatrix: Before proof is for a different cell than block %d", BlockNumber)
+  }
+
+  return DeletionProof{Before: Before, After: After}, nil
+}
+
+// VerifyDeletionProof checks that PreDeletionHash validates against
+// dp.Before, that dp.After's cell is genuinely empty, that both proofs
+// describe the same cell, that the cell's row and column siblings are
+// unchanged, and that every other row and column hash is unchanged between
+// dp.Before and dp.After
+
+func VerifyDeletionProof( dp DeletionProof, PreDeletionHash string, matrixDigest string ) bool {
+
+  if !VerifyInclusionProof(dp.Before, PreDeletionHash, matrixDigest) { return false }
+  if !VerifyInclusionProof(dp.After, "", matrixDigest) { return false }
+  if dp.Before.Row != dp.After.Row || dp.Before.Col != dp.After.Col { return false }
+  if dp.Before.Dimension != dp.After.Dimension { return false }
+
+  for k := range dp.Before.RowSiblings {
+    if dp.Before.RowSiblings[k] != dp.After.RowSiblings[k] { return false }
+  }
+
+  for k := range dp.Before.ColSiblings {
+    if dp.Before.ColSiblings[k] != dp.After.ColSiblings[k] { return false }
+  }
+
+  for k := 0; k < dp.Before.Dimension; k++ {
+
+    if k == dp.Before.Row { continue }
+    if dp.Before.RowHashes[k] != dp.After.RowHashes[k] { return false }
+  }
+
+  for k := 0; k < dp.Before.Dimension; k++ {
+
+    if k == dp.Before.Col { continue }
+    if dp.Before.ColumnHashes[k] != dp.After.ColumnHashes[k] { return false }
+  }
+
+  return true
+}
+
+// reconstructRowCells rebuilds row proof.Row's per-column hash list,
+// substituting blockHash at proof.Col and proof.RowSiblings everywhere
+// else (the diagonal entry at proof.Row is left zero since it's excluded
+// from the row hash computation anyway)
+
+func reconstructRowCells( proof Proof, blockHash string ) []string {
+
+  cells := make([]string, proof.Dimension)
+  s := 0
+
+  for k := 0; k < proof.Dimension; k++ {
+
+    if k == proof.Row {
+      continue
+    } else if k == proof.Col {
+      cells[k] = blockHash
+    } else {
+      cells[k] = proof.RowSiblings[s]
+      s++
+    }
+  }
+
+  return cells
+}
+
+// reconstructColCells is the column analog of reconstructRowCells
+
+func reconstructColCells( proof Proof, blockHash string ) []string {
+
+  cells := make([]string, proof.Dimension)
+  s := 0
+
+  for k := 0; k < proof.Dimension; k++ {
+
+    if k == proof.Col {
+      continue
+    } else if k == proof.Row {
+      cells[k] = blockHash
+    } else {
+      cells[k] = proof.ColSiblings[s]
+      s++
+    }
+  }
+
+  return cells
+}
+
+// computeRowHashFromCells mirrors recomputeRowMatrix/computeRowHash: for
+// TZHash it's the GF(2^127) matrix product of every cell but the diagonal,
+// for the SHA family it's HasherFunc (or HashAlgorithm)'s hash of their
+// concatenation, same as the source matrix would have computed
+
+func computeRowHashFromCells( HashAlgorithm string, HasherFunc func() hash.Hash, Dimension int, Row int, cells []string ) string {
+
+  if HashAlgorithm == TZHash {
+
+    m := gf127.IdentityMatrix()
+
+    for k := 0; k < Dimension; k++ {
+
+      if k == Row { continue }
+      m = m.Mul(cellMatrixOf(cells[k]))
+    }
+
+    return hex.EncodeToString(m.Bytes())
+  }
+
+  hr := newHasherFor(HasherFunc, HashAlgorithm)
+  for k := 0; k < Dimension; k++ { if k != Row { hr.WriteString(cells[k]) } }
+
+  return hr.SumHex()
+}
+
+// computeColHashFromCells is the column analog of computeRowHashFromCells
+
+func computeColHashFromCells( HashAlgorithm string, HasherFunc func() hash.Hash, Dimension int, Col int, cells []string ) string {
+
+  if HashAlgorithm == TZHash {
+
+    m := gf127.IdentityMatrix()
+
+    for k := 0; k < Dimension; k++ {
+
+      if k == Col { continue }
+      m = m.Mul(cellMatrixOf(cells[k]))
+    }
+
+    return hex.EncodeToString(m.Bytes())
+  }
+
+  hr := newHasherFor(HasherFunc, HashAlgorithm)
+  for k := 0; k < Dimension; k++ { if k != Col { hr.WriteString(cells[k]) } }
+
+  return hr.SumHex()
+}
+
+// cellMatrixOf parses a TZHash cell hash back into its GF(2^127) matrix,
+// treating an empty (deleted or never written) cell as the identity, same
+// as deleteBlockAt does for the live cache
+
+func cellMatrixOf( cellHash string ) gf127.Matrix {
+
+  if cellHash == "" { return gf127.IdentityMatrix() }
+
+  b := mustHexDecode(cellHash)
+  if len(b) != 64 { return gf127.Matrix{} } // malformed/tampered hash, never matches a real matrix
+
+  return gf127.MatrixFromBytes(b)
+}
+
+// combineHashList mirrors updateHashOfRows/updateHashOfColumns: the
+// concatenation of a hash list, hashed once more with HasherFunc (or
+// HashAlgorithm)'s hasher
+
+func combineHashList( HashAlgorithm string, HasherFunc func() hash.Hash, hashes []string ) string {
+
+  hr := newHasherFor(HasherFunc, HashAlgorithm)
+  for _, h := range hashes { hr.WriteString(h) }
+
+  return hr.SumHex()
+}
@@ -0,0 +1,115 @@
+package blockmatrix
+
+import "testing"
+
+// inclusionProofAcceptsAndRejects builds a matrix under algo, inserts
+// blocks, then checks that GetInclusionProof/VerifyInclusionProof accepts
+// the real hash of a present block and rejects both a tampered hash and a
+// tampered proof
+
+func inclusionProofAcceptsAndRejects( t *testing.T, algo string ) {
+
+  dim := 6
+  bm := Create(dim, algo)
+  bm.InsertBlocks(fillDimWithBlocks(dim))
+
+  const BlockNumber = 2
+  blockHash := bm.GetBlockHash(BlockNumber)
+
+  proof, err := bm.GetInclusionProof(BlockNumber)
+  if err != nil { t.Fatalf("GetInclusionProof: %v", err) }
+
+  if !VerifyInclusionProof(proof, blockHash, bm.HashOfMatrix) {
+    t.Fatalf("%s: VerifyInclusionProof rejected a valid proof", algo)
+  }
+
+  if VerifyInclusionProof(proof, "not-the-real-hash", bm.HashOfMatrix) {
+    t.Fatalf("%s: VerifyInclusionProof accepted a tampered block hash", algo)
+  }
+
+  tampered := proof
+  tampered.RowHashes = append([]string(nil), proof.RowHashes...)
+  tampered.RowHashes[proof.Row] = "not-the-real-row-hash"
+
+  if VerifyInclusionProof(tampered, blockHash, bm.HashOfMatrix) {
+    t.Fatalf("%s: VerifyInclusionProof accepted a tampered RowHashes entry", algo)
+  }
+
+  if VerifyInclusionProof(proof, blockHash, "not-the-real-matrix-digest") {
+    t.Fatalf("%s: VerifyInclusionProof accepted a tampered matrix digest", algo)
+  }
+}
+
+func TestInclusionProofSha256( t *testing.T ) { inclusionProofAcceptsAndRejects(t, Sha256) }
+func TestInclusionProofTZHash( t *testing.T ) { inclusionProofAcceptsAndRejects(t, TZHash) }
+
+// deletionProofAcceptsAndRejects captures an inclusion proof before a GDPR
+// deletion, deletes the block, and checks that GetDeletionProof/
+// VerifyDeletionProof accepts the real before-hash and rejects a tampered one
+
+func deletionProofAcceptsAndRejects( t *testing.T, algo string ) {
+
+  dim := 6
+  bm := Create(dim, algo)
+  bm.InsertBlocks(fillDimWithBlocks(dim))
+
+  const BlockNumber = 3
+  preDeletionHash := bm.GetBlockHash(BlockNumber)
+
+  before, err := bm.GetInclusionProof(BlockNumber)
+  if err != nil { t.Fatalf("GetInclusionProof (before): %v", err) }
+
+  if !bm.DeleteBlock(BlockNumber) { t.Fatalf("DeleteBlock(%d) failed", BlockNumber) }
+
+  dp, err := bm.GetDeletionProof(BlockNumber, before)
+  if err != nil { t.Fatalf("GetDeletionProof: %v", err) }
+
+  if !VerifyDeletionProof(dp, preDeletionHash, bm.HashOfMatrix) {
+    t.Fatalf("%s: VerifyDeletionProof rejected a valid deletion", algo)
+  }
+
+  if VerifyDeletionProof(dp, "not-the-real-pre-deletion-hash", bm.HashOfMatrix) {
+    t.Fatalf("%s: VerifyDeletionProof accepted a tampered pre-deletion hash", algo)
+  }
+
+  tampered := dp
+  tampered.After.RowHashes = append([]string(nil), dp.After.RowHashes...)
+  tampered.After.RowHashes[(dp.After.Row+1)%dim] = "not-the-real-row-hash"
+
+  if VerifyDeletionProof(tampered, preDeletionHash, bm.HashOfMatrix) {
+    t.Fatalf("%s: VerifyDeletionProof accepted a tampered sibling row hash", algo)
+  }
+}
+
+func TestDeletionProofSha256( t *testing.T ) { deletionProofAcceptsAndRejects(t, Sha256) }
+func TestDeletionProofTZHash( t *testing.T ) { deletionProofAcceptsAndRejects(t, TZHash) }
+
+// TestInclusionProofPartiallyFilledTZHash covers a TZHash matrix that isn't
+// fully populated, since InsertBlocks never requires filling every cell: a
+// never-written cellMatrices entry must contribute identity to its row/
+// column product, not the gf127.Matrix zero value, or every row/column
+// touching it would wrongly fail every inclusion proof
+
+func TestInclusionProofPartiallyFilledTZHash( t *testing.T ) {
+
+  dim := 6
+  bm := Create(dim, TZHash)
+
+  all := fillDimWithBlocks(dim)
+  bm.InsertBlocks(all[:len(all)-2]) // leave cells (4,5) and (5,4) unwritten
+
+  const BlockNumber = 14 // maps to cell (4,0): same row as the unwritten (4,5) cell
+  blockHash := bm.GetBlockHash(BlockNumber)
+  if blockHash == "" { t.Fatalf("block %d should have been written by the partial insert", BlockNumber) }
+
+  proof, err := bm.GetInclusionProof(BlockNumber)
+  if err != nil { t.Fatalf("GetInclusionProof: %v", err) }
+
+  if !VerifyInclusionProof(proof, blockHash, bm.HashOfMatrix) {
+    t.Fatalf("VerifyInclusionProof rejected a valid proof on a partially-filled TZHash matrix")
+  }
+
+  if VerifyInclusionProof(proof, "not-the-real-hash", bm.HashOfMatrix) {
+    t.Fatalf("VerifyInclusionProof accepted a tampered block hash on a partially-filled TZHash matrix")
+  }
+}
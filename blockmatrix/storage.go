@@ -0,0 +1,205 @@
+package blockmatrix
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strconv"
+  "sync"
+)
+
+// Storage is a pluggable persistence backend for a BlockMatrix. It lets
+// BlockData live outside of RAM so that an N x N matrix of blocks that
+// won't fit in memory can still be built, mutated and later reopened.
+// Implementations only need to store opaque bytes; BlockMatrix is
+// responsible for everything hash-related
+
+type Storage interface {
+
+  PutBlock( i int, j int, data []byte ) error
+  GetBlock( i int, j int ) ( []byte, error )
+  DeleteBlock( i int, j int ) error
+  PutMeta( key string, v []byte ) error
+  GetMeta( key string ) ( []byte, error )
+}
+
+// SeekableStorage is implemented by Storage backends that can hand back a
+// seekable stream for a block instead of loading the whole cell into
+// memory. GetBlockDataReader uses it when available
+
+type SeekableStorage interface {
+
+  Storage
+  OpenBlock( i int, j int ) ( io.ReadSeekCloser, error )
+}
+
+// MemoryStorage is the in-memory Storage backend. Create does not use it
+// (a plain Create matrix has no Storage and keeps cells in BlockData); it is
+// kept here mainly so storage-backed code paths can be exercised without
+// touching the filesystem. It guards its maps with a
+// mutex since no constructor currently combines a Storage backend with
+// Options.Parallelism, but PutBlock/PutMeta would be driven from multiple
+// InsertBlocks workers at once the day one does (see BlockMatrix.storageErr
+// for the matching guard on the BlockMatrix side)
+
+type MemoryStorage struct {
+
+  mu sync.Mutex
+  blocks map[[2]int][]byte
+  meta map[string][]byte
+}
+
+// NewMemoryStorage returns an empty in-memory Storage backend
+
+func NewMemoryStorage() *MemoryStorage {
+
+  return &MemoryStorage{
+    blocks: make(map[[2]int][]byte),
+    meta: make(map[string][]byte),
+  }
+}
+
+func (s *MemoryStorage) PutBlock( i int, j int, data []byte ) error {
+
+  cp := make([]byte, len(data))
+  copy(cp, data)
+
+  s.mu.Lock()
+  s.blocks[[2]int{i, j}] = cp
+  s.mu.Unlock()
+
+  return nil
+}
+
+func (s *MemoryStorage) GetBlock( i int, j int ) ( []byte, error ) {
+
+  s.mu.Lock()
+  data, ok := s.blocks[[2]int{i, j}]
+  s.mu.Unlock()
+
+  if !ok { return nil, fmt.Errorf("blockmatrix: no block stored at (%d, %d)", i, j) }
+
+  return data, nil
+}
+
+func (s *MemoryStorage) DeleteBlock( i int, j int ) error {
+
+  s.mu.Lock()
+  delete(s.blocks, [2]int{i, j})
+  s.mu.Unlock()
+
+  return nil
+}
+
+func (s *MemoryStorage) PutMeta( key string, v []byte ) error {
+
+  cp := make([]byte, len(v))
+  copy(cp, v)
+
+  s.mu.Lock()
+  s.meta[key] = cp
+  s.mu.Unlock()
+
+  return nil
+}
+
+func (s *MemoryStorage) GetMeta( key string ) ( []byte, error ) {
+
+  s.mu.Lock()
+  v, ok := s.meta[key]
+  s.mu.Unlock()
+
+  if !ok { return nil, fmt.Errorf("blockmatrix: no meta stored for key %q", key) }
+
+  return v, nil
+}
+
+// FileStorage is a filesystem-backed Storage implementation. Each cell is
+// written to its own file at <Root>/<i>/<j>.blk, and each meta key to its
+// own file under <Root>/meta/. Blocks are written with an exclusive-create
+// flag: a cell file may not be silently overwritten, it must be deleted
+// first, which matches the GDPR erase-then-refill lifecycle of a cell
+
+type FileStorage struct {
+
+  Root string
+}
+
+// NewFileStorage returns a FileStorage rooted at dir. The directory is
+// created on first use, not by this constructor
+
+func NewFileStorage( dir string ) *FileStorage {
+
+  return &FileStorage{Root: dir}
+}
+
+func (s *FileStorage) blockPath( i int, j int ) string {
+
+  return filepath.Join(s.Root, strconv.Itoa(i), strconv.Itoa(j) + ".blk")
+}
+
+func (s *FileStorage) metaPath( key string ) string {
+
+  return filepath.Join(s.Root, "meta", key)
+}
+
+func (s *FileStorage) PutBlock( i int, j int, data []byte ) error {
+
+  path := s.blockPath(i, j)
+
+  if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { return err }
+
+  f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+  if err != nil { return err }
+  defer f.Close()
+
+  _, err = f.Write(data)
+  return err
+}
+
+func (s *FileStorage) GetBlock( i int, j int ) ( []byte, error ) {
+
+  return os.ReadFile(s.blockPath(i, j))
+}
+
+func (s *FileStorage) DeleteBlock( i int, j int ) error {
+
+  err := os.Remove(s.blockPath(i, j))
+  if err != nil && os.IsNotExist(err) { return nil }
+
+  return err
+}
+
+// OpenBlock returns a seekable, streamable handle on a cell's file so
+// large blocks can be range-read without loading the whole cell
+
+func (s *FileStorage) OpenBlock( i int, j int ) ( io.ReadSeekCloser, error ) {
+
+  return os.Open(s.blockPath(i, j))
+}
+
+func (s *FileStorage) PutMeta( key string, v []byte ) error {
+
+  path := s.metaPath(key)
+
+  if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { return err }
+
+  return os.WriteFile(path, v, 0644)
+}
+
+func (s *FileStorage) GetMeta( key string ) ( []byte, error ) {
+
+  return os.ReadFile(s.metaPath(key))
+}
+
+// nopCloserReader adapts a bytes.Reader to io.ReadSeekCloser for the
+// non-storage-backed (fully in-memory) GetBlockDataReader path
+
+type nopCloserReader struct {
+
+  *bytes.Reader
+}
+
+func (nopCloserReader) Close() error { return nil }
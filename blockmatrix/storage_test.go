@@ -0,0 +1,99 @@
+package blockmatrix
+
+import (
+  "bytes"
+  "testing"
+)
+
+// storageRoundTrip exercises CreateWithStorage -> InsertBlocks -> DeleteBlock
+// (GDPR erasure) -> Open against a given Storage backend, checking that the
+// reopened matrix's verifiable state (row/column hashes and HashOfMatrix)
+// matches the original, and that the deleted block's hash is empty
+
+func storageRoundTrip( t *testing.T, dim int, newStorage func() Storage ) {
+
+  storage := newStorage()
+
+  bm, err := CreateWithStorage(dim, Sha256, storage)
+  if err != nil { t.Fatalf("CreateWithStorage: %v", err) }
+
+  bm.InsertBlocks(fillDimWithBlocks(dim))
+  bm.DeleteBlock(1)
+
+  wantHashOfRows := bm.GetHashOfRows()
+  wantHashOfColumns := bm.GetHashOfColumns()
+  wantHashOfMatrix := bm.HashOfMatrix
+
+  reopened, err := Open(storage)
+  if err != nil { t.Fatalf("Open: %v", err) }
+
+  if reopened.GetHashOfRows() != wantHashOfRows {
+    t.Fatalf("HashOfRows after Open %q != before %q", reopened.GetHashOfRows(), wantHashOfRows)
+  }
+
+  if reopened.GetHashOfColumns() != wantHashOfColumns {
+    t.Fatalf("HashOfColumns after Open %q != before %q", reopened.GetHashOfColumns(), wantHashOfColumns)
+  }
+
+  if reopened.HashOfMatrix != wantHashOfMatrix {
+    t.Fatalf("HashOfMatrix after Open %q != before %q", reopened.HashOfMatrix, wantHashOfMatrix)
+  }
+
+  i, j := bm.blockIndex(1)
+  if reopened.BlockHashes[i][j] != "" {
+    t.Fatalf("block 1 should be GDPR-erased, still has hash %q after Open", reopened.BlockHashes[i][j])
+  }
+}
+
+func TestMemoryStorageRoundTrip( t *testing.T ) {
+
+  storageRoundTrip(t, 6, func() Storage { return NewMemoryStorage() })
+}
+
+func TestFileStorageRoundTrip( t *testing.T ) {
+
+  dir := t.TempDir()
+  storageRoundTrip(t, 6, func() Storage { return NewFileStorage(dir) })
+}
+
+// TestRejectedStorageWriteLeavesPriorStateIntact covers inserting into an
+// already-populated cell of a FileStorage-backed matrix (FileStorage's
+// O_EXCL write rejects overwriting a cell file, and nothing in the API
+// forbids calling InsertBlocks twice). The rejected PutBlock must not be
+// allowed to also change the recorded BlockHash/cellMatrices entry or the
+// stored bytes: a reader should still see the original content and hash
+
+func TestRejectedStorageWriteLeavesPriorStateIntact( t *testing.T ) {
+
+  dim := 6
+  storage := NewFileStorage(t.TempDir())
+
+  bm, err := CreateWithStorage(dim, Sha256, storage)
+  if err != nil { t.Fatalf("CreateWithStorage: %v", err) }
+
+  bm.InsertBlocks(fillDimWithBlocks(dim))
+  if err := bm.StorageErr(); err != nil { t.Fatalf("unexpected StorageErr after first insert: %v", err) }
+
+  const BlockNumber = 1
+  originalHash := bm.GetBlockHash(BlockNumber)
+
+  var overwrite bytes.Buffer
+  overwrite.WriteString("this should never make it into storage")
+  bm.setCellHash(0, 1, overwrite) // (0,1) is block 1's cell, per blockIndex
+
+  if bm.StorageErr() == nil {
+    t.Fatalf("expected StorageErr to be set after a rejected PutBlock, got nil")
+  }
+
+  if bm.GetBlockHash(BlockNumber) != originalHash {
+    t.Fatalf("BlockHash changed to %q after a rejected write, want unchanged %q", bm.GetBlockHash(BlockNumber), originalHash)
+  }
+
+  i, j := bm.blockIndex(BlockNumber)
+  stored, err := storage.GetBlock(i, j)
+  if err != nil { t.Fatalf("GetBlock: %v", err) }
+
+  if string(stored) == overwrite.String() {
+    t.Fatalf("storage was overwritten despite PutBlock returning an error")
+  }
+}